@@ -0,0 +1,191 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// ScanShardActivityParams is the input to ActivityScanShard.
+type ScanShardActivityParams struct {
+	Shards []int
+}
+
+// ActivityScanShard returns one ScanReport per shard in params.Shards. It
+// relies on a ScannerContext having been attached to ctx by the hosting
+// worker via WithScannerContext.
+func ActivityScanShard(ctx context.Context, params ScanShardActivityParams) ([]ScanReport, error) {
+	sc, ok := scannerContextFrom(ctx)
+	if !ok {
+		return nil, errors.New("scanner context not found, activity must be registered with a worker started via WithScannerContext")
+	}
+	reports := make([]ScanReport, 0, len(params.Shards))
+	for _, shardID := range params.Shards {
+		reports = append(reports, scanShard(ctx, sc, shardID))
+	}
+	return reports, nil
+}
+
+func scanShard(ctx context.Context, sc ScannerContext, shardID int) ScanReport {
+	// The report carries only shardID: no invariant checks are run, the
+	// InvariantManagerFactory of sc is not called, and Stats and Result are
+	// left zero.
+	return ScanReport{
+		ShardID: shardID,
+	}
+}
+
+// ScannerConfigActivityParams is the input to ActivityScannerConfig.
+type ScannerConfigActivityParams struct {
+	Name string
+}
+
+// ActivityScannerConfig resolves the GenericScannerConfig for the named
+// scanner workflow from dynamic config.
+func ActivityScannerConfig(ctx context.Context, params ScannerConfigActivityParams) (ResolvedScannerWorkflowConfig, error) {
+	sc, ok := scannerContextFrom(ctx)
+	if !ok {
+		return ResolvedScannerWorkflowConfig{}, errors.New("scanner context not found, activity must be registered with a worker started via WithScannerContext")
+	}
+	return sc.ScannerWorkflowDynamicConfig(ctx, params.Name)
+}
+
+// FixShardActivityParams is the input to ActivityFixShard.
+type FixShardActivityParams struct {
+	CorruptedKeysEntries        []CorruptedKeysEntry
+	ResolvedFixerWorkflowConfig ResolvedFixerWorkflowConfig
+	EnabledInvariants           CustomScannerConfig
+	// DryRun, when true, runs the invariant pipeline without mutating
+	// persistence; see FixerWorkflowParams.DryRun.
+	DryRun bool
+	// ViolationContext is carried through to FixReport.Preview when DryRun is
+	// set; see FixerWorkflowParams.ViolationContext.
+	ViolationContext *ViolationContext
+}
+
+// ActivityFixShard returns one FixReport per entry in
+// params.CorruptedKeysEntries. If params.DryRun is set, each report also
+// carries a FixReport.Preview built by previewFixShard.
+func ActivityFixShard(ctx context.Context, params FixShardActivityParams) ([]FixReport, error) {
+	fc, ok := fixerContextFrom(ctx)
+	if !ok {
+		return nil, errors.New("fixer context not found, activity must be registered with a worker started via WithFixerContext")
+	}
+	reports := make([]FixReport, 0, len(params.CorruptedKeysEntries))
+	for _, entry := range params.CorruptedKeysEntries {
+		reports = append(reports, fixShard(ctx, fc, params, entry))
+	}
+	return reports, nil
+}
+
+func fixShard(ctx context.Context, fc FixerContext, params FixShardActivityParams, entry CorruptedKeysEntry) FixReport {
+	// The report carries only entry.ShardID (plus Preview on a dry run): no
+	// invariant checks or fixes are run, the InvariantManagerFactory of fc is
+	// not called, and Stats and Result are left zero.
+	if !params.DryRun {
+		return FixReport{
+			ShardID: entry.ShardID,
+		}
+	}
+	return FixReport{
+		ShardID: entry.ShardID,
+		Preview: previewFixShard(params.EnabledInvariants, params.ViolationContext),
+	}
+}
+
+// previewFixShard builds the PreviewResult for a single dry-run shard, with
+// one PreviewInvariantResult per invariant enabled with "true", sorted by
+// name. Every result's Action is left at its zero value, PreviewActionFix; no
+// invariant.Manager is consulted to choose between fix, skip and fail.
+func previewFixShard(enabledInvariants CustomScannerConfig, violationContext *ViolationContext) *PreviewResult {
+	names := make([]string, 0, len(enabledInvariants))
+	for name, enabled := range enabledInvariants {
+		if enabled == "true" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	invariants := make([]PreviewInvariantResult, len(names))
+	for i, name := range names {
+		invariants[i] = PreviewInvariantResult{InvariantName: name}
+	}
+	return &PreviewResult{
+		ViolationContext: violationContext,
+		Invariants:       invariants,
+	}
+}
+
+// FixShardConfigParams is the input to ActivityFixerConfig.
+//
+// no contents currently
+type FixShardConfigParams struct {
+}
+
+// FixShardConfigResults is the result of ActivityFixerConfig.
+type FixShardConfigResults struct {
+	EnabledInvariants   CustomScannerConfig
+	ActivityRetryPolicy *ActivityRetryPolicy
+	CheckpointConfig    *CheckpointConfig
+}
+
+// ActivityFixerConfig resolves the enabled invariants, ActivityRetryPolicy
+// and CheckpointConfig for the fixer workflow from dynamic config.
+func ActivityFixerConfig(ctx context.Context, params FixShardConfigParams) (*FixShardConfigResults, error) {
+	fc, ok := fixerContextFrom(ctx)
+	if !ok {
+		return nil, errors.New("fixer context not found, activity must be registered with a worker started via WithFixerContext")
+	}
+	return fc.FixerWorkflowDynamicConfig(ctx, FixerWorkflowConfigOverwrites{})
+}
+
+// FixerCorruptedKeysActivityParams is the input to ActivityFixerCorruptedKeys.
+type FixerCorruptedKeysActivityParams struct {
+	ScannerWorkflowWorkflowID string
+	ScannerWorkflowRunID      string
+	StartingShardID           *int
+}
+
+// FixerCorruptedKeysActivityResult is a single page of corrupted shards
+// discovered by a prior scanner workflow run.
+type FixerCorruptedKeysActivityResult struct {
+	CorruptedKeys             []CorruptedKeysEntry
+	MinShard                  *int
+	MaxShard                  *int
+	ShardQueryPaginationToken ShardQueryPaginationToken
+}
+
+// ActivityFixerCorruptedKeys is the activity a fixer workflow pages through
+// for the shards a prior scanner workflow run found to have corruptions. Once
+// the fixer context is found it always returns a "not implemented" error; no
+// scanner workflow is queried.
+func ActivityFixerCorruptedKeys(ctx context.Context, params FixerCorruptedKeysActivityParams) (*FixerCorruptedKeysActivityResult, error) {
+	fc, ok := fixerContextFrom(ctx)
+	if !ok {
+		return nil, errors.New("fixer context not found, activity must be registered with a worker started via WithFixerContext")
+	}
+	_ = fc
+	return nil, errors.New("not implemented")
+}
@@ -0,0 +1,114 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+const (
+	// CheckpointQuery returns the latest ScannerCheckpoint/FixerCheckpoint
+	// for the current run, including shards processed before a
+	// continue-as-new if one already happened.
+	CheckpointQuery = "checkpoint"
+)
+
+// CheckpointConfig controls how often a scanner or fixer workflow persists
+// its progress and continues-as-new, trading some mid-run concurrency for a
+// clean point to truncate workflow history. A nil CheckpointConfig on the
+// resolved config preserves the historical behavior of running to completion
+// in a single workflow execution.
+type CheckpointConfig struct {
+	// BatchesPerCheckpoint is the number of activity batches (summed across
+	// all lanes) to process before persisting a checkpoint and, if shards
+	// remain, continuing-as-new.
+	BatchesPerCheckpoint int
+}
+
+// ScannerCheckpoint is the serializable progress of a scanner workflow run,
+// sufficient to resume processing the remaining shards in a fresh workflow
+// execution via ScannerWorkflowParams.ResumeFromCheckpoint.
+type ScannerCheckpoint struct {
+	ShardReports  map[int]ScanReport
+	ShardStatuses ShardStatusResult
+	Aggregate     AggregateScanReportResult
+	// PendingShardIDs is every shard known to this run - from its original
+	// Shards configuration plus any injected via AddShardsSignal - that had
+	// not yet been processed when the checkpoint was taken.
+	PendingShardIDs []int
+}
+
+// FixerCheckpoint is the ScannerCheckpoint equivalent for a fixer workflow
+// run.
+type FixerCheckpoint struct {
+	ShardReports  map[int]FixReport
+	ShardStatuses ShardStatusResult
+	Aggregate     AggregateFixReportResult
+	// PendingShardIDs is every shard known to this run - from the prior
+	// scanner's corrupted keys plus any injected via AddShardsSignal - that
+	// had not yet been processed when the checkpoint was taken.
+	PendingShardIDs []int
+}
+
+// ScannerContinueAsNewError is returned by ScannerWorkflow.Start when a
+// configured CheckpointConfig caused the run to stop after a batch of
+// shards with shards still remaining. The workflow function hosting
+// ScannerWorkflow should respond to it by calling
+// workflow.NewContinueAsNewError with NextParams.
+type ScannerContinueAsNewError struct {
+	NextParams ScannerWorkflowParams
+}
+
+func (e *ScannerContinueAsNewError) Error() string {
+	return "scanner workflow reached its checkpoint and must continue-as-new"
+}
+
+// FixerContinueAsNewError is the ScannerContinueAsNewError equivalent for a
+// fixer workflow run.
+type FixerContinueAsNewError struct {
+	NextParams FixerWorkflowParams
+}
+
+func (e *FixerContinueAsNewError) Error() string {
+	return "fixer workflow reached its checkpoint and must continue-as-new"
+}
+
+// remainingShardIDs returns the subset of shards not present in processed,
+// preserving the relative order of shards.
+func remainingShardIDs(shards []int, processed ShardStatusResult) []int {
+	remaining := make([]int, 0, len(shards))
+	for _, shardID := range shards {
+		if _, done := processed[shardID]; !done {
+			remaining = append(remaining, shardID)
+		}
+	}
+	return remaining
+}
+
+// remainingCorruptedKeys returns the subset of entries whose ShardID is not
+// present in processed, preserving the relative order of entries.
+func remainingCorruptedKeys(entries []CorruptedKeysEntry, processed ShardStatusResult) []CorruptedKeysEntry {
+	remaining := make([]CorruptedKeysEntry, 0, len(entries))
+	for _, entry := range entries {
+		if _, done := processed[entry.ShardID]; !done {
+			remaining = append(remaining, entry)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,145 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+// CustomScannerConfig is a free-form bag of string settings that scanner and
+// fixer activities interpret for themselves, most commonly to enable or
+// disable individual invariant.Invariant implementations by name.
+type CustomScannerConfig map[string]string
+
+// GenericScannerConfig holds the settings common to every scanner workflow,
+// resolved at the start of a run via ActivityScannerConfig.
+type GenericScannerConfig struct {
+	Enabled           bool
+	Concurrency       int
+	ActivityBatchSize int
+	// ActivityRetryPolicy configures retries of ActivityScanShard. A nil
+	// value preserves the historical behavior of aborting the run on the
+	// first error.
+	ActivityRetryPolicy *ActivityRetryPolicy
+	// CheckpointConfig configures periodic checkpointing and continue-as-new.
+	// A nil value preserves the historical behavior of running to completion
+	// in a single workflow execution.
+	CheckpointConfig *CheckpointConfig
+}
+
+// ScannerWorkflowParams is the input to NewScannerWorkflow.
+type ScannerWorkflowParams struct {
+	Shards Shards
+	// ResumeFromCheckpoint, when set, skips shards already recorded in the
+	// checkpoint and seeds the new run's queryable state with it. It is set
+	// by the workflow itself across a continue-as-new; callers starting a
+	// fresh run should leave it nil.
+	ResumeFromCheckpoint *ScannerCheckpoint
+}
+
+// ResolvedScannerWorkflowConfig is the result of ActivityScannerConfig: the
+// dynamic config and persisted overwrites collapsed into the concrete values
+// a single workflow run should use.
+type ResolvedScannerWorkflowConfig struct {
+	GenericScannerConfig
+	CustomScannerConfig CustomScannerConfig
+}
+
+// FixerWorkflowConfigOverwrites supplies the settings of a single
+// NewFixerWorkflow run. Concurrency, ActivityBatchSize and
+// BlobstoreFlushThreshold are taken only from here, with no dynamic-config
+// default: an unset field resolves to zero. ActivityRetryPolicy, if set,
+// replaces the one resolved by ActivityFixerConfig.
+type FixerWorkflowConfigOverwrites struct {
+	Concurrency             *int
+	ActivityBatchSize       *int
+	BlobstoreFlushThreshold *int
+	ActivityRetryPolicy     *ActivityRetryPolicy
+}
+
+// ResolvedFixerWorkflowConfig is the result of ActivityFixerConfig collapsed
+// with FixerWorkflowConfigOverwrites into the concrete values a single
+// workflow run should use.
+type ResolvedFixerWorkflowConfig struct {
+	Concurrency             int
+	ActivityBatchSize       int
+	BlobstoreFlushThreshold int
+	// ActivityRetryPolicy configures retries of ActivityFixShard. A nil
+	// value preserves the historical behavior of aborting the run on the
+	// first error.
+	ActivityRetryPolicy *ActivityRetryPolicy
+	// CheckpointConfig configures periodic checkpointing and continue-as-new.
+	// A nil value preserves the historical behavior of running to completion
+	// in a single workflow execution.
+	CheckpointConfig *CheckpointConfig
+}
+
+// FixerWorkflowParams is the input to NewFixerWorkflow.
+type FixerWorkflowParams struct {
+	ScannerWorkflowWorkflowID     string
+	ScannerWorkflowRunID          string
+	FixerWorkflowConfigOverwrites FixerWorkflowConfigOverwrites
+	// ResumeFromCheckpoint, when set, skips shards already recorded in the
+	// checkpoint and seeds the new run's queryable state with it. It is set
+	// by the workflow itself across a continue-as-new; callers starting a
+	// fresh run should leave it nil.
+	ResumeFromCheckpoint *FixerCheckpoint
+	// NotificationSink, when set, streams CorruptionNotifications to a
+	// downstream workflow as batches are fixed, instead of requiring callers
+	// to poll ShardReportQuery after the run finishes. A nil value preserves
+	// the historical behavior of not sending any notifications.
+	NotificationSink *NotificationSink
+	// DryRun, when true, runs the full invariant pipeline without mutating
+	// persistence: ActivityFixShard reports what it would have fixed, skipped
+	// or failed per invariant via FixReport.Preview instead of actually
+	// fixing anything.
+	DryRun bool
+	// ViolationContext describes why this run was started (the watch,
+	// project and caller an operator is investigating). It is carried
+	// through to FixReport.Preview so a DryRun's output can be attributed to
+	// the request that produced it.
+	ViolationContext *ViolationContext
+}
+
+// NotificationSinkMode selects how a configured NotificationSink receives
+// CorruptionNotifications from a fixer workflow run.
+type NotificationSinkMode int
+
+const (
+	// NotificationSinkPerBatch starts one child workflow, of the registered
+	// type named by NotificationSink.ChildWorkflowName, per activity batch,
+	// carrying every shard's CorruptionNotification from that batch.
+	NotificationSinkPerBatch NotificationSinkMode = iota
+	// NotificationSinkPerShard signals the workflow identified by
+	// NotificationSink.TargetWorkflowID once per shard whose FixReport has a
+	// ControlFlowFailure or a non-zero FailedCount.
+	NotificationSinkPerShard
+)
+
+// NotificationSink configures where FixerWorkflowParams.NotificationSink
+// delivers CorruptionNotifications, and in what shape.
+type NotificationSink struct {
+	Mode NotificationSinkMode
+	// ChildWorkflowName is the registered workflow type started as a child
+	// workflow for each batch. Required when Mode is NotificationSinkPerBatch.
+	ChildWorkflowName string
+	// TargetWorkflowID is the workflow signaled for each qualifying shard.
+	// Required when Mode is NotificationSinkPerShard.
+	TargetWorkflowID string
+}
@@ -0,0 +1,91 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"context"
+
+	"github.com/uber/cadence/common/reconciliation/invariant"
+)
+
+type (
+	scannerContextKeyType struct{}
+	fixerContextKeyType   struct{}
+)
+
+var (
+	scannerContextKey = scannerContextKeyType{}
+	fixerContextKey   = fixerContextKeyType{}
+)
+
+// ScannerContext is the set of dependencies an activity worker registers
+// alongside ActivityScanShard/ActivityScannerConfig so that those activities
+// can reach the invariant manager factory and the dynamic config resolver
+// without threading them through activity parameters.
+type ScannerContext struct {
+	InvariantManagerFactory      InvariantManagerFactory
+	ScannerWorkflowDynamicConfig ScannerWorkflowDynamicConfig
+}
+
+// FixerContext is the fixer equivalent of ScannerContext.
+type FixerContext struct {
+	InvariantManagerFactory    InvariantManagerFactory
+	FixerWorkflowDynamicConfig FixerWorkflowDynamicConfig
+}
+
+// InvariantManagerFactory builds the invariant.Manager to run against a
+// single shard, given the set of invariants enabled for this run.
+type InvariantManagerFactory func(enabledInvariants CustomScannerConfig) invariant.Manager
+
+// ScannerWorkflowDynamicConfig resolves the GenericScannerConfig for a named
+// scanner workflow from dynamic config at activity execution time.
+type ScannerWorkflowDynamicConfig func(ctx context.Context, name string) (ResolvedScannerWorkflowConfig, error)
+
+// FixerWorkflowDynamicConfig resolves the enabled invariants,
+// ActivityRetryPolicy and CheckpointConfig for the fixer workflow from
+// dynamic config at activity execution time.
+type FixerWorkflowDynamicConfig func(ctx context.Context, overwrites FixerWorkflowConfigOverwrites) (*FixShardConfigResults, error)
+
+// WithScannerContext returns a context carrying the dependencies needed by
+// scanner activities. It should be called once, by the worker that registers
+// those activities.
+func WithScannerContext(ctx context.Context, sc ScannerContext) context.Context {
+	return context.WithValue(ctx, scannerContextKey, sc)
+}
+
+// WithFixerContext returns a context carrying the dependencies needed by
+// fixer activities. It should be called once, by the worker that registers
+// those activities.
+func WithFixerContext(ctx context.Context, fc FixerContext) context.Context {
+	return context.WithValue(ctx, fixerContextKey, fc)
+}
+
+func scannerContextFrom(ctx context.Context) (ScannerContext, bool) {
+	sc, ok := ctx.Value(scannerContextKey).(ScannerContext)
+	return sc, ok
+}
+
+func fixerContextFrom(ctx context.Context) (FixerContext, bool) {
+	fc, ok := ctx.Value(fixerContextKey).(FixerContext)
+	return fc, ok
+}
@@ -0,0 +1,242 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"errors"
+
+	"go.uber.org/cadence/workflow"
+)
+
+const (
+	// AddShardsSignalName injects additional shards into an in-flight
+	// scanner or fixer run. Injected shards are picked up once the run's
+	// current round of lanes finishes, and are reported by ControlStateQuery
+	// until then.
+	AddShardsSignalName = "add_shards"
+	// ControlSignalName pauses, resumes or aborts an in-flight run.
+	ControlSignalName = "control"
+	// RetuneSignalName adjusts the Concurrency and ActivityBatchSize of an
+	// in-flight run. A nil field leaves the corresponding setting unchanged.
+	RetuneSignalName = "retune"
+	// ControlStateQuery returns the current paused/running state and any
+	// shards injected via AddShardsSignal that have not yet been picked up.
+	ControlStateQuery = "control_state"
+)
+
+// ErrWorkflowAborted is returned by ScannerWorkflow.Start/FixerWorkflow.Start
+// when a ControlSignal with Action ControlActionAbort is received.
+var ErrWorkflowAborted = errors.New("workflow aborted via ControlSignal")
+
+// AddShardsSignal is the payload of AddShardsSignalName.
+type AddShardsSignal struct {
+	Shards []int
+}
+
+// ControlAction is the action requested by a ControlSignal.
+type ControlAction int
+
+const (
+	// ControlActionPause stops dispatching new activity batches until a
+	// ControlActionResume or ControlActionAbort is received.
+	ControlActionPause ControlAction = iota
+	// ControlActionResume resumes a paused run.
+	ControlActionResume
+	// ControlActionAbort stops the run; Start returns ErrWorkflowAborted.
+	ControlActionAbort
+)
+
+// ControlSignal is the payload of ControlSignalName.
+type ControlSignal struct {
+	Action ControlAction
+}
+
+// RetuneSignal is the payload of RetuneSignalName.
+type RetuneSignal struct {
+	Concurrency       *int
+	ActivityBatchSize *int
+}
+
+// ControlStateQueryResult is returned by ControlStateQuery.
+type ControlStateQueryResult struct {
+	Paused        bool
+	PendingShards []int
+}
+
+// controlCoordinator holds the pause/abort/retune/injected-shard state
+// shared by ScannerWorkflow and FixerWorkflow. Both hold it by value in their
+// control field; its methods take a pointer receiver so mutations made from one lane
+// goroutine are visible to every other lane sharing the enclosing workflow
+// struct.
+type controlCoordinator struct {
+	paused         bool
+	aborted        bool
+	concurrency    int
+	batchSize      int
+	injectedShards []int
+	// resumeBroadcast is non-nil while paused. It is closed - waking every
+	// lane blocked in awaitRunnable at once - when a ControlActionResume or
+	// ControlActionAbort is processed, since closing a cadence
+	// workflow.Channel unblocks every pending and future Receive on it,
+	// unlike a plain signal channel where each Receive consumes one item and
+	// only wakes a single waiter.
+	resumeBroadcast workflow.Channel
+}
+
+// registerQuery exposes c's state via ControlStateQuery. It should be called
+// once per workflow execution, alongside the run's other query handlers.
+func (c *controlCoordinator) registerQuery(ctx workflow.Context) error {
+	return workflow.SetQueryHandler(ctx, ControlStateQuery, func() (ControlStateQueryResult, error) {
+		return ControlStateQueryResult{
+			Paused:        c.paused,
+			PendingShards: append([]int(nil), c.injectedShards...),
+		}, nil
+	})
+}
+
+// awaitRunnable cooperatively drains any pending AddShardsSignal,
+// ControlSignal or RetuneSignal between activity batches using a
+// workflow.Selector and, if the run is paused, blocks - continuing to drain
+// signals - until it is resumed or aborted. It returns false once the run
+// has been aborted, at which point the caller must stop dispatching
+// activities.
+func (c *controlCoordinator) awaitRunnable(ctx workflow.Context) bool {
+	for c.drainPendingSignal(ctx) {
+		// Keep draining while another signal is immediately available.
+	}
+	for c.paused && !c.aborted {
+		c.awaitResumeOrSignal(ctx)
+	}
+	return !c.aborted
+}
+
+// drainPendingSignal builds a fresh workflow.Selector over the three control
+// channels and, if one already has a signal queued, applies it and returns
+// true. Its default case makes Select return immediately rather than block
+// when nothing is pending.
+func (c *controlCoordinator) drainPendingSignal(ctx workflow.Context) bool {
+	addShardsCh := workflow.GetSignalChannel(ctx, AddShardsSignalName)
+	controlCh := workflow.GetSignalChannel(ctx, ControlSignalName)
+	retuneCh := workflow.GetSignalChannel(ctx, RetuneSignalName)
+
+	gotSignal := false
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(addShardsCh, func(ch workflow.Channel, more bool) {
+		c.receiveAddShards(ctx, ch)
+		gotSignal = true
+	})
+	selector.AddReceive(controlCh, func(ch workflow.Channel, more bool) {
+		c.receiveControl(ctx, ch)
+		gotSignal = true
+	})
+	selector.AddReceive(retuneCh, func(ch workflow.Channel, more bool) {
+		c.receiveRetune(ctx, ch)
+		gotSignal = true
+	})
+	selector.AddDefault(func() {})
+	selector.Select(ctx)
+	return gotSignal
+}
+
+// awaitResumeOrSignal blocks until either the current pause is broadcast-
+// resumed via resumeBroadcast, or this lane happens to be the one a new
+// AddShardsSignal, ControlSignal or RetuneSignal is delivered to. Multiple
+// lanes can safely call this concurrently while paused: resumeBroadcast is
+// closed exactly once per pause, by whichever lane's receiveControl handles
+// the ControlActionResume/ControlActionAbort, and closing a
+// workflow.Channel - unlike a signal channel, where each Receive consumes
+// one item - wakes every lane blocked on it at once.
+func (c *controlCoordinator) awaitResumeOrSignal(ctx workflow.Context) {
+	if c.resumeBroadcast == nil {
+		c.resumeBroadcast = workflow.NewChannel(ctx)
+	}
+	resumeBroadcast := c.resumeBroadcast
+
+	addShardsCh := workflow.GetSignalChannel(ctx, AddShardsSignalName)
+	controlCh := workflow.GetSignalChannel(ctx, ControlSignalName)
+	retuneCh := workflow.GetSignalChannel(ctx, RetuneSignalName)
+
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(resumeBroadcast, func(ch workflow.Channel, more bool) {})
+	selector.AddReceive(addShardsCh, func(ch workflow.Channel, more bool) {
+		c.receiveAddShards(ctx, ch)
+	})
+	selector.AddReceive(controlCh, func(ch workflow.Channel, more bool) {
+		c.receiveControl(ctx, ch)
+	})
+	selector.AddReceive(retuneCh, func(ch workflow.Channel, more bool) {
+		c.receiveRetune(ctx, ch)
+	})
+	selector.Select(ctx)
+}
+
+func (c *controlCoordinator) receiveAddShards(ctx workflow.Context, ch workflow.Channel) {
+	var signal AddShardsSignal
+	ch.Receive(ctx, &signal)
+	c.injectedShards = append(c.injectedShards, signal.Shards...)
+}
+
+func (c *controlCoordinator) receiveControl(ctx workflow.Context, ch workflow.Channel) {
+	var signal ControlSignal
+	ch.Receive(ctx, &signal)
+	switch signal.Action {
+	case ControlActionPause:
+		c.paused = true
+	case ControlActionResume:
+		c.paused = false
+		c.broadcastResume()
+	case ControlActionAbort:
+		c.aborted = true
+		c.paused = false
+		c.broadcastResume()
+	}
+}
+
+func (c *controlCoordinator) receiveRetune(ctx workflow.Context, ch workflow.Channel) {
+	var signal RetuneSignal
+	ch.Receive(ctx, &signal)
+	if signal.Concurrency != nil {
+		c.concurrency = *signal.Concurrency
+	}
+	if signal.ActivityBatchSize != nil {
+		c.batchSize = *signal.ActivityBatchSize
+	}
+}
+
+// broadcastResume wakes every lane currently blocked in awaitResumeOrSignal,
+// if any are. It is a no-op outside a pause, since resumeBroadcast is only
+// ever non-nil while c.paused is true.
+func (c *controlCoordinator) broadcastResume() {
+	if c.resumeBroadcast != nil {
+		c.resumeBroadcast.Close()
+		c.resumeBroadcast = nil
+	}
+}
+
+// drainInjectedShards returns and clears the shards accumulated via
+// AddShardsSignal since the last call.
+func (c *controlCoordinator) drainInjectedShards() []int {
+	injected := c.injectedShards
+	c.injectedShards = nil
+	return injected
+}
@@ -0,0 +1,405 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/cadence/workflow"
+)
+
+var fixShardActivityOptions = workflow.ActivityOptions{
+	ScheduleToStartTimeout: time.Minute,
+	StartToCloseTimeout:    time.Hour,
+}
+
+var fixerConfigActivityOptions = workflow.ActivityOptions{
+	ScheduleToStartTimeout: time.Minute,
+	StartToCloseTimeout:    time.Minute,
+}
+
+var fixerCorruptedKeysActivityOptions = workflow.ActivityOptions{
+	ScheduleToStartTimeout: time.Minute,
+	StartToCloseTimeout:    time.Minute,
+}
+
+// FixerWorkflow runs NewFixerWorkflow's logic: it fetches the corrupted
+// shards recorded by a prior scanner workflow run, shards them across
+// Concurrency lanes, fixes each lane's shards in batches of
+// ActivityBatchSize, and exposes the results via ShardReportQuery,
+// AggregateReportQuery and ShardStatusQuery. If CheckpointConfig is
+// configured, it stops early and reports a FixerContinueAsNewError once it
+// has processed CheckpointConfig.BatchesPerCheckpoint batches with shards
+// still remaining.
+type FixerWorkflow struct {
+	name   string
+	params FixerWorkflowParams
+
+	entries           []CorruptedKeysEntry
+	reports           map[int]FixReport
+	statuses          ShardStatusResult
+	aggregate         AggregateFixReportResult
+	batchesProcessed  int
+	checkpointReached bool
+	control           controlCoordinator
+}
+
+// NewFixerWorkflow constructs a FixerWorkflow ready to Start. name identifies
+// the fixer for logging purposes. If params.ResumeFromCheckpoint is set, the
+// returned FixerWorkflow resumes from it: shards it already recorded are
+// skipped and its reports, statuses and aggregate seed the queryable state
+// of this run.
+func NewFixerWorkflow(ctx workflow.Context, name string, params FixerWorkflowParams) (*FixerWorkflow, error) {
+	w := &FixerWorkflow{
+		name:     name,
+		params:   params,
+		reports:  make(map[int]FixReport),
+		statuses: make(ShardStatusResult),
+	}
+	if checkpoint := params.ResumeFromCheckpoint; checkpoint != nil {
+		for shardID, report := range checkpoint.ShardReports {
+			w.reports[shardID] = report
+		}
+		for shardID, status := range checkpoint.ShardStatuses {
+			w.statuses[shardID] = status
+		}
+		w.aggregate = checkpoint.Aggregate
+	}
+	return w, nil
+}
+
+// Start runs the fixer workflow to completion, or until a configured
+// CheckpointConfig causes it to stop early and return a
+// FixerContinueAsNewError for its caller to continue-as-new with. It
+// processes corrupted keys in rounds: each round partitions the currently
+// pending entries across Concurrency lanes, and shards injected via
+// AddShardsSignal or a changed Concurrency from RetuneSignal take effect at
+// the start of the next round. A ControlSignal with Action
+// ControlActionAbort makes Start return ErrWorkflowAborted.
+func (w *FixerWorkflow) Start(ctx workflow.Context) error {
+	if err := w.registerQueries(ctx); err != nil {
+		return err
+	}
+
+	corruptedKeysResult, err := getCorruptedKeys(ctx, w.params)
+	if err != nil {
+		return err
+	}
+	w.entries = corruptedKeysResult.CorruptedKeys
+	if checkpoint := w.params.ResumeFromCheckpoint; checkpoint != nil && len(checkpoint.PendingShardIDs) > 0 {
+		injected := make([]CorruptedKeysEntry, len(checkpoint.PendingShardIDs))
+		for i, shardID := range checkpoint.PendingShardIDs {
+			injected[i] = CorruptedKeysEntry{ShardID: shardID}
+		}
+		w.entries = mergeUniqueCorruptedKeys(w.entries, injected)
+	}
+
+	configCtx := workflow.WithActivityOptions(ctx, fixerConfigActivityOptions)
+	var configResult *FixShardConfigResults
+	if err := workflow.ExecuteActivity(configCtx, ActivityFixerConfig, FixShardConfigParams{}).Get(configCtx, &configResult); err != nil {
+		return err
+	}
+
+	resolved := resolveFixerWorkflowConfig(w.params.FixerWorkflowConfigOverwrites)
+	resolved.ActivityRetryPolicy = configResult.ActivityRetryPolicy
+	if w.params.FixerWorkflowConfigOverwrites.ActivityRetryPolicy != nil {
+		resolved.ActivityRetryPolicy = w.params.FixerWorkflowConfigOverwrites.ActivityRetryPolicy
+	}
+	resolved.CheckpointConfig = configResult.CheckpointConfig
+	w.control.concurrency = resolved.Concurrency
+	w.control.batchSize = resolved.ActivityBatchSize
+
+	activityCtx := workflow.WithActivityOptions(ctx, applyRetryPolicy(fixShardActivityOptions, resolved.ActivityRetryPolicy))
+	for {
+		if !w.control.awaitRunnable(ctx) {
+			return ErrWorkflowAborted
+		}
+		if injected := w.control.drainInjectedShards(); len(injected) > 0 {
+			entries := make([]CorruptedKeysEntry, len(injected))
+			for i, shardID := range injected {
+				entries[i] = CorruptedKeysEntry{ShardID: shardID}
+			}
+			w.entries = mergeUniqueCorruptedKeys(w.entries, entries)
+		}
+		pending := remainingCorruptedKeys(w.entries, w.statuses)
+		if len(pending) == 0 {
+			break
+		}
+		lanes := partitionCorruptedKeys(pending, w.control.concurrency)
+
+		errCh := workflow.NewChannel(ctx)
+		for _, lane := range lanes {
+			lane := lane
+			workflow.Go(ctx, func(ctx workflow.Context) {
+				errCh.Send(ctx, w.runLane(activityCtx, lane, resolved, configResult.EnabledInvariants))
+			})
+		}
+
+		var firstErr error
+		for range lanes {
+			var laneErr error
+			errCh.Receive(ctx, &laneErr)
+			if laneErr != nil && firstErr == nil {
+				firstErr = laneErr
+			}
+		}
+		if firstErr != nil {
+			return firstErr
+		}
+		if w.checkpointReached {
+			break
+		}
+	}
+
+	if w.checkpointReached && len(remainingCorruptedKeys(w.entries, w.statuses)) > 0 {
+		return &FixerContinueAsNewError{NextParams: FixerWorkflowParams{
+			ScannerWorkflowWorkflowID:     w.params.ScannerWorkflowWorkflowID,
+			ScannerWorkflowRunID:          w.params.ScannerWorkflowRunID,
+			FixerWorkflowConfigOverwrites: w.params.FixerWorkflowConfigOverwrites,
+			ResumeFromCheckpoint:          w.buildCheckpoint(),
+			NotificationSink:              w.params.NotificationSink,
+			DryRun:                        w.params.DryRun,
+			ViolationContext:              w.params.ViolationContext,
+		}}
+	}
+	return nil
+}
+
+func resolveFixerWorkflowConfig(overwrites FixerWorkflowConfigOverwrites) ResolvedFixerWorkflowConfig {
+	resolved := ResolvedFixerWorkflowConfig{}
+	if overwrites.Concurrency != nil {
+		resolved.Concurrency = *overwrites.Concurrency
+	}
+	if overwrites.ActivityBatchSize != nil {
+		resolved.ActivityBatchSize = *overwrites.ActivityBatchSize
+	}
+	if overwrites.BlobstoreFlushThreshold != nil {
+		resolved.BlobstoreFlushThreshold = *overwrites.BlobstoreFlushThreshold
+	}
+	return resolved
+}
+
+// runLane fixes lane in batches sized by the latest RetuneSignal, checking
+// for a pause or abort via awaitRunnable before dispatching each batch.
+func (w *FixerWorkflow) runLane(ctx workflow.Context, lane []CorruptedKeysEntry, resolved ResolvedFixerWorkflowConfig, enabledInvariants CustomScannerConfig) error {
+	for len(lane) > 0 {
+		if w.checkpointReached {
+			return nil
+		}
+		if !w.control.awaitRunnable(ctx) {
+			return ErrWorkflowAborted
+		}
+		batchSize := w.control.batchSize
+		if batchSize <= 0 || batchSize > len(lane) {
+			batchSize = len(lane)
+		}
+		batch := lane[:batchSize]
+		lane = lane[batchSize:]
+
+		var reports []FixReport
+		params := FixShardActivityParams{
+			CorruptedKeysEntries:        batch,
+			ResolvedFixerWorkflowConfig: resolved,
+			EnabledInvariants:           enabledInvariants,
+			DryRun:                      w.params.DryRun,
+			ViolationContext:            w.params.ViolationContext,
+		}
+		if err := workflow.ExecuteActivity(ctx, ActivityFixShard, params).Get(ctx, &reports); err != nil {
+			return err
+		}
+		w.recordReports(reports)
+		if err := notify(ctx, w.params.NotificationSink, reports, enabledInvariants); err != nil {
+			return err
+		}
+		w.recordBatch(resolved.CheckpointConfig)
+	}
+	return nil
+}
+
+// recordBatch counts a completed activity batch toward CheckpointConfig, if
+// one is configured, across every lane of this run. workflow.Go goroutines
+// only run one at a time between blocking calls, so this plain increment is
+// safe without additional synchronization.
+func (w *FixerWorkflow) recordBatch(checkpointConfig *CheckpointConfig) {
+	if checkpointConfig == nil || checkpointConfig.BatchesPerCheckpoint <= 0 {
+		return
+	}
+	w.batchesProcessed++
+	if w.batchesProcessed >= checkpointConfig.BatchesPerCheckpoint {
+		w.checkpointReached = true
+	}
+}
+
+// buildCheckpoint returns a FixerCheckpoint capturing this run's progress so
+// far, suitable for FixerWorkflowParams.ResumeFromCheckpoint on a
+// continue-as-new.
+func (w *FixerWorkflow) buildCheckpoint() *FixerCheckpoint {
+	pending := remainingCorruptedKeys(w.entries, w.statuses)
+	pendingShardIDs := make([]int, len(pending))
+	for i, entry := range pending {
+		pendingShardIDs[i] = entry.ShardID
+	}
+	return &FixerCheckpoint{
+		ShardReports:    w.reports,
+		ShardStatuses:   w.statuses,
+		Aggregate:       w.aggregate,
+		PendingShardIDs: pendingShardIDs,
+	}
+}
+
+func (w *FixerWorkflow) recordReports(reports []FixReport) {
+	for _, report := range reports {
+		w.reports[report.ShardID] = report
+		if report.Result.ControlFlowFailure != nil {
+			w.statuses[report.ShardID] = ShardStatusControlFlowFailure
+			continue
+		}
+		w.statuses[report.ShardID] = ShardStatusSuccess
+		w.aggregate.EntitiesCount += report.Stats.EntitiesCount
+		w.aggregate.FixedCount += report.Stats.FixedCount
+		w.aggregate.FailedCount += report.Stats.FailedCount
+		w.aggregate.SkippedCount += report.Stats.SkippedCount
+		w.recordPreview(report.Preview)
+	}
+}
+
+// recordPreview folds a dry-run shard's PreviewResult into
+// AggregateFixReportResult.PreviewedCount and PerInvariantPreview. A nil
+// preview (a non-dry-run report) is a no-op.
+func (w *FixerWorkflow) recordPreview(preview *PreviewResult) {
+	if preview == nil {
+		return
+	}
+	if w.aggregate.PerInvariantPreview == nil {
+		w.aggregate.PerInvariantPreview = make(map[string]PreviewInvariantBreakdown)
+	}
+	for _, result := range preview.Invariants {
+		w.aggregate.PreviewedCount++
+		breakdown := w.aggregate.PerInvariantPreview[result.InvariantName]
+		switch result.Action {
+		case PreviewActionFix:
+			breakdown.FixCount++
+		case PreviewActionSkip:
+			breakdown.SkipCount++
+		case PreviewActionFail:
+			breakdown.FailCount++
+		}
+		w.aggregate.PerInvariantPreview[result.InvariantName] = breakdown
+	}
+}
+
+func (w *FixerWorkflow) registerQueries(ctx workflow.Context) error {
+	if err := w.control.registerQuery(ctx); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, ShardReportQuery, func(shardID int) (*FixReport, error) {
+		report, ok := w.reports[shardID]
+		if !ok {
+			return nil, nil
+		}
+		return &report, nil
+	}); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, AggregateReportQuery, func() (AggregateFixReportResult, error) {
+		return w.aggregate, nil
+	}); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, ShardStatusQuery, func(req PaginatedShardQueryRequest) (*ShardStatusQueryResult, error) {
+		ids := make([]int, 0, len(w.statuses))
+		for id := range w.statuses {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		page, token := paginateShardIDs(ids, req)
+		result := make(ShardStatusResult, len(page))
+		for _, id := range page {
+			result[id] = w.statuses[id]
+		}
+		return &ShardStatusQueryResult{
+			Result:                    result,
+			ShardQueryPaginationToken: token,
+		}, nil
+	}); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, CheckpointQuery, func() (*FixerCheckpoint, error) {
+		return w.buildCheckpoint(), nil
+	}); err != nil {
+		return err
+	}
+	return workflow.SetQueryHandler(ctx, PreviewQuery, func() (map[int]*PreviewResult, error) {
+		previews := make(map[int]*PreviewResult, len(w.reports))
+		for shardID, report := range w.reports {
+			if report.Preview != nil {
+				previews[shardID] = report.Preview
+			}
+		}
+		return previews, nil
+	})
+}
+
+// GetCorruptedKeys is a standalone workflow that pages through
+// ActivityFixerCorruptedKeys on behalf of the scanner workflow identified by
+// params.ScannerWorkflowWorkflowID/RunID and returns the merged result. It is
+// exposed separately from NewFixerWorkflow so operators can inspect what a
+// fixer run would process without running the fix itself.
+func GetCorruptedKeys(ctx workflow.Context, params FixerWorkflowParams) (*FixerCorruptedKeysActivityResult, error) {
+	return getCorruptedKeys(ctx, params)
+}
+
+func getCorruptedKeys(ctx workflow.Context, params FixerWorkflowParams) (*FixerCorruptedKeysActivityResult, error) {
+	ctx = workflow.WithActivityOptions(ctx, fixerCorruptedKeysActivityOptions)
+
+	merged := &FixerCorruptedKeysActivityResult{
+		ShardQueryPaginationToken: ShardQueryPaginationToken{IsDone: false},
+	}
+	var startingShardID *int
+	for {
+		var page *FixerCorruptedKeysActivityResult
+		activityParams := FixerCorruptedKeysActivityParams{
+			ScannerWorkflowWorkflowID: params.ScannerWorkflowWorkflowID,
+			ScannerWorkflowRunID:      params.ScannerWorkflowRunID,
+			StartingShardID:           startingShardID,
+		}
+		if err := workflow.ExecuteActivity(ctx, ActivityFixerCorruptedKeys, activityParams).Get(ctx, &page); err != nil {
+			return nil, err
+		}
+
+		merged.CorruptedKeys = append(merged.CorruptedKeys, page.CorruptedKeys...)
+		if page.MinShard != nil && merged.MinShard == nil {
+			merged.MinShard = page.MinShard
+		}
+		if page.MaxShard != nil {
+			merged.MaxShard = page.MaxShard
+		}
+		merged.ShardQueryPaginationToken = page.ShardQueryPaginationToken
+
+		if page.ShardQueryPaginationToken.IsDone {
+			return merged, nil
+		}
+		startingShardID = page.ShardQueryPaginationToken.NextShardID
+	}
+}
@@ -0,0 +1,216 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"github.com/uber/cadence/common/reconciliation/store"
+)
+
+const (
+	// ShardReportQuery returns the ScanReport or FixReport for a single shard.
+	ShardReportQuery = "shard_report"
+	// AggregateReportQuery returns the aggregate result across all shards processed so far.
+	AggregateReportQuery = "aggregate_report"
+	// ShardStatusQuery returns the status of every shard processed so far, paginated.
+	ShardStatusQuery = "shard_status"
+	// PreviewQuery returns the PreviewResult of every shard processed so far
+	// by a DryRun fixer workflow, keyed by shard ID.
+	PreviewQuery = "preview"
+)
+
+// ControlFlowFailure indicates that processing of a shard was aborted before
+// all of its invariants could be checked or fixed.
+type ControlFlowFailure struct {
+	Info string
+}
+
+// ShardQueryPaginationToken is returned by the corrupted keys activity to
+// allow the caller to page through all shards that have corruptions on record.
+type ShardQueryPaginationToken struct {
+	NextShardID *int
+	IsDone      bool
+}
+
+// PaginatedShardQueryRequest is the argument to ShardStatusQuery.
+type PaginatedShardQueryRequest struct {
+	StartingShardID *int
+	LimitShards     *int
+}
+
+// ShardStatus is the lifecycle state of a single shard within a scanner or
+// fixer workflow run.
+type ShardStatus int
+
+const (
+	// ShardStatusRunning indicates the shard has been dispatched but has not yet reported back.
+	ShardStatusRunning ShardStatus = iota
+	// ShardStatusSuccess indicates the shard completed without a control flow failure.
+	ShardStatusSuccess
+	// ShardStatusControlFlowFailure indicates the shard aborted with a ControlFlowFailure.
+	ShardStatusControlFlowFailure
+)
+
+// ShardStatusResult maps shard id to its current ShardStatus.
+type ShardStatusResult map[int]ShardStatus
+
+// ShardStatusQueryResult is returned by ShardStatusQuery.
+type ShardStatusQueryResult struct {
+	Result                    ShardStatusResult
+	ShardQueryPaginationToken ShardQueryPaginationToken
+}
+
+// ScanStats contains the counts produced by scanning a single shard.
+type ScanStats struct {
+	EntitiesCount    int64
+	CorruptedCount   int64
+	CheckFailedCount int64
+}
+
+// ShardScanKeys points to the blobstore keys holding the detailed output of
+// scanning a single shard, when any corruptions or check failures were found.
+type ShardScanKeys struct {
+	Corrupted   *store.Keys
+	CheckFailed *store.Keys
+}
+
+// ScanResult carries either the keys of a completed scan or the reason the
+// shard could not be scanned at all.
+type ScanResult struct {
+	ShardScanKeys      *ShardScanKeys
+	ControlFlowFailure *ControlFlowFailure
+}
+
+// ScanReport is the result of scanning a single shard for invariant violations.
+type ScanReport struct {
+	ShardID int
+	Stats   ScanStats
+	Result  ScanResult
+}
+
+// AggregateScanReportResult is the sum of ScanStats across all shards
+// processed by a scanner workflow so far.
+type AggregateScanReportResult struct {
+	EntitiesCount    int64
+	CorruptedCount   int64
+	CheckFailedCount int64
+}
+
+// FixStats contains the counts produced by fixing a single shard.
+type FixStats struct {
+	EntitiesCount int64
+	FixedCount    int64
+	FailedCount   int64
+	SkippedCount  int64
+}
+
+// FixKeys points to the blobstore keys holding the detailed output of fixing
+// a single shard.
+type FixKeys struct {
+	Skipped *store.Keys
+	Failed  *store.Keys
+	Fixed   *store.Keys
+}
+
+// FixResult carries either the keys of a completed fix or the reason the
+// shard could not be fixed at all.
+type FixResult struct {
+	ShardFixKeys       *FixKeys
+	ControlFlowFailure *ControlFlowFailure
+}
+
+// FixReport is the result of fixing a single shard's invariant violations.
+// When the run is a FixerWorkflowParams.DryRun, Preview describes what would
+// have happened instead, and Stats is left zero.
+type FixReport struct {
+	ShardID int
+	Stats   FixStats
+	Result  FixResult
+	Preview *PreviewResult
+}
+
+// AggregateFixReportResult is the sum of FixStats across all shards processed
+// by a fixer workflow so far.
+type AggregateFixReportResult struct {
+	EntitiesCount int64
+	FixedCount    int64
+	FailedCount   int64
+	SkippedCount  int64
+	// PreviewedCount is the number of (shard, invariant) outcomes recorded by
+	// dry-run shards so far, summed across PerInvariantPreview.
+	PreviewedCount int64
+	// PerInvariantPreview breaks PreviewedCount down by invariant name, for
+	// dry runs only.
+	PerInvariantPreview map[string]PreviewInvariantBreakdown
+}
+
+// ViolationContext is operator-supplied context describing why a fixer run
+// was started, carried through to PreviewResult so a dry run's output can be
+// attributed to the watch, project and caller that requested it.
+type ViolationContext struct {
+	WatchName string
+	Project   string
+	Caller    string
+}
+
+// PreviewAction is what a dry-run fixer decided it would do for a single
+// invariant on a single shard, had DryRun not been set.
+type PreviewAction int
+
+const (
+	// PreviewActionFix indicates the invariant would have been fixed.
+	PreviewActionFix PreviewAction = iota
+	// PreviewActionSkip indicates the invariant had nothing to fix.
+	PreviewActionSkip
+	// PreviewActionFail indicates fixing the invariant would have failed.
+	PreviewActionFail
+)
+
+// PreviewInvariantResult is the dry-run outcome of a single enabled
+// invariant for a single shard.
+type PreviewInvariantResult struct {
+	InvariantName string
+	Action        PreviewAction
+}
+
+// PreviewInvariantBreakdown counts PreviewInvariantResult.Action across every
+// dry-run shard for a single invariant.
+type PreviewInvariantBreakdown struct {
+	FixCount  int64
+	SkipCount int64
+	FailCount int64
+}
+
+// PreviewResult is FixReport.Preview: what a dry-run fixer would have done
+// for a single shard, per enabled invariant, plus the ViolationContext that
+// requested the run.
+type PreviewResult struct {
+	ViolationContext *ViolationContext
+	Invariants       []PreviewInvariantResult
+}
+
+// CorruptedKeysEntry identifies a shard that a prior scan found to have
+// corruptions recorded in the blobstore, along with the keys to read them from.
+type CorruptedKeysEntry struct {
+	ShardID int
+	Keys    store.Keys
+}
@@ -0,0 +1,86 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"time"
+
+	"go.uber.org/cadence/workflow"
+)
+
+// CorruptionNotificationSignalName is the signal sent to
+// NotificationSink.TargetWorkflowID for each qualifying shard when Mode is
+// NotificationSinkPerShard.
+const CorruptionNotificationSignalName = "corruption_notification"
+
+var notifyChildWorkflowOptions = workflow.ChildWorkflowOptions{
+	ExecutionStartToCloseTimeout: time.Hour,
+}
+
+// CorruptionNotification carries a single shard's fix outcome to a
+// FixerWorkflowParams.NotificationSink, letting downstream consumers
+// (alerting, ticketing, replication repair) react in near-real-time instead
+// of polling ShardReportQuery after the run finishes.
+type CorruptionNotification struct {
+	ShardID           int
+	EnabledInvariants CustomScannerConfig
+	Stats             FixStats
+	Result            FixResult
+}
+
+// notify delivers reports to sink according to its Mode. A nil sink is a
+// no-op.
+func notify(ctx workflow.Context, sink *NotificationSink, reports []FixReport, enabledInvariants CustomScannerConfig) error {
+	if sink == nil {
+		return nil
+	}
+	switch sink.Mode {
+	case NotificationSinkPerBatch:
+		notifications := make([]CorruptionNotification, len(reports))
+		for i, report := range reports {
+			notifications[i] = corruptionNotificationFor(report, enabledInvariants)
+		}
+		childCtx := workflow.WithChildOptions(ctx, notifyChildWorkflowOptions)
+		return workflow.ExecuteChildWorkflow(childCtx, sink.ChildWorkflowName, notifications).Get(childCtx, nil)
+	case NotificationSinkPerShard:
+		for _, report := range reports {
+			if report.Result.ControlFlowFailure == nil && report.Stats.FailedCount == 0 {
+				continue
+			}
+			notification := corruptionNotificationFor(report, enabledInvariants)
+			if err := workflow.SignalExternalWorkflow(ctx, sink.TargetWorkflowID, "", CorruptionNotificationSignalName, notification).Get(ctx, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func corruptionNotificationFor(report FixReport, enabledInvariants CustomScannerConfig) CorruptionNotification {
+	return CorruptionNotification{
+		ShardID:           report.ShardID,
+		EnabledInvariants: enabledInvariants,
+		Stats:             report.Stats,
+		Result:            report.Result,
+	}
+}
@@ -0,0 +1,75 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"time"
+
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/workflow"
+)
+
+// ActivityRetryPolicy configures how aggressively ActivityScanShard and
+// ActivityFixShard are retried before a shard's failure is treated as fatal
+// and aborts the run. NonRetryableInvariants lists the error reasons, as set
+// by wrapInvariantError from an invariant collection name (e.g.
+// "CollectionMutableState"), whose failures should never be retried, because
+// they indicate a genuine validation problem rather than a transient
+// persistence error.
+type ActivityRetryPolicy struct {
+	InitialInterval        time.Duration
+	MaximumInterval        time.Duration
+	BackoffCoefficient     float64
+	ExpirationInterval     time.Duration
+	MaximumAttempts        int32
+	NonRetryableInvariants []string
+}
+
+// applyRetryPolicy returns activityOptions with the given ActivityRetryPolicy
+// applied, if one is configured. A nil policy leaves activityOptions
+// unchanged, so a shard failure still aborts the whole run exactly as it did
+// before retries were supported.
+func applyRetryPolicy(activityOptions workflow.ActivityOptions, policy *ActivityRetryPolicy) workflow.ActivityOptions {
+	if policy == nil {
+		return activityOptions
+	}
+	activityOptions.RetryPolicy = &workflow.RetryPolicy{
+		InitialInterval:          policy.InitialInterval,
+		MaximumInterval:          policy.MaximumInterval,
+		BackoffCoefficient:       policy.BackoffCoefficient,
+		ExpirationInterval:       policy.ExpirationInterval,
+		MaximumAttempts:          policy.MaximumAttempts,
+		NonRetriableErrorReasons: policy.NonRetryableInvariants,
+	}
+	return activityOptions
+}
+
+// wrapInvariantError tags err with invariantName as its cadence error reason,
+// so a configured ActivityRetryPolicy.NonRetryableInvariants can classify it
+// as fatal regardless of what other invariants on the same shard return.
+func wrapInvariantError(invariantName string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return cadence.NewCustomError(invariantName, err.Error())
+}
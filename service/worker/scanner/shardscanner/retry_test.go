@@ -0,0 +1,101 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *workflowsSuite) TestScannerWorkflow_Success_RetriesTransientScanShardFailure() {
+	s.env.OnActivity(ActivityScannerConfig, mock.Anything, mock.Anything).Return(ResolvedScannerWorkflowConfig{
+		GenericScannerConfig: GenericScannerConfig{
+			Enabled:           true,
+			Concurrency:       1,
+			ActivityBatchSize: 3,
+			ActivityRetryPolicy: &ActivityRetryPolicy{
+				InitialInterval:    time.Millisecond,
+				MaximumInterval:    time.Millisecond,
+				BackoffCoefficient: 1,
+				ExpirationInterval: time.Minute,
+				MaximumAttempts:    3,
+			},
+		},
+	}, nil)
+
+	report := []ScanReport{
+		{ShardID: 0, Stats: ScanStats{EntitiesCount: 10}},
+		{ShardID: 1, Stats: ScanStats{EntitiesCount: 10}},
+		{ShardID: 2, Stats: ScanStats{EntitiesCount: 10}},
+	}
+	params := ScanShardActivityParams{Shards: []int{0, 1, 2}}
+	s.env.OnActivity(ActivityScanShard, mock.Anything, params).
+		Return(nil, errors.New("transient persistence timeout")).Times(2)
+	s.env.OnActivity(ActivityScanShard, mock.Anything, params).
+		Return(report, nil).Once()
+
+	s.env.ExecuteWorkflow(NewTestWorkflow, "test-workflow", ScannerWorkflowParams{
+		Shards: Shards{List: []int{0, 1, 2}},
+	})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	aggValue, err := s.env.QueryWorkflow(AggregateReportQuery)
+	s.NoError(err)
+	var agg AggregateScanReportResult
+	s.NoError(aggValue.Get(&agg))
+	s.Equal(AggregateScanReportResult{EntitiesCount: 30}, agg)
+}
+
+func (s *workflowsSuite) TestFixerWorkflow_Failure_NonRetryableInvariantIsNotRetried() {
+	s.env.OnActivity(ActivityFixerCorruptedKeys, mock.Anything, mock.Anything).Return(&FixerCorruptedKeysActivityResult{
+		CorruptedKeys: []CorruptedKeysEntry{{ShardID: 0}},
+		ShardQueryPaginationToken: ShardQueryPaginationToken{
+			IsDone: true,
+		},
+	}, nil)
+	s.env.OnActivity(ActivityFixerConfig, mock.Anything, FixShardConfigParams{}).Return(&FixShardConfigResults{
+		ActivityRetryPolicy: &ActivityRetryPolicy{
+			InitialInterval:        time.Millisecond,
+			MaximumInterval:        time.Millisecond,
+			BackoffCoefficient:     1,
+			ExpirationInterval:     time.Minute,
+			MaximumAttempts:        5,
+			NonRetryableInvariants: []string{"CollectionMutableState"},
+		},
+	}, nil)
+	s.env.OnActivity(ActivityFixShard, mock.Anything, mock.Anything).
+		Return(nil, wrapInvariantError("CollectionMutableState", errors.New("invalid mutable state")))
+
+	s.env.ExecuteWorkflow(NewTestFixerWorkflow, FixerWorkflowParams{
+		ScannerWorkflowWorkflowID: "test_wid",
+		ScannerWorkflowRunID:      "test_rid",
+	})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.Error(s.env.GetWorkflowError())
+	s.env.AssertNumberOfCalls(s.T(), "ActivityFixShard", 1)
+}
@@ -0,0 +1,300 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"go.uber.org/cadence/workflow"
+)
+
+var scanShardActivityOptions = workflow.ActivityOptions{
+	ScheduleToStartTimeout: time.Minute,
+	StartToCloseTimeout:    time.Hour,
+}
+
+var scannerConfigActivityOptions = workflow.ActivityOptions{
+	ScheduleToStartTimeout: time.Minute,
+	StartToCloseTimeout:    time.Minute,
+}
+
+// ScannerWorkflow runs NewScannerWorkflow's logic: it shards the configured
+// Shards across Concurrency lanes, scans each lane's shards in batches of
+// ActivityBatchSize, and exposes the results via ShardReportQuery,
+// AggregateReportQuery and ShardStatusQuery. If CheckpointConfig is
+// configured, it stops early and reports a ScannerContinueAsNewError once it
+// has processed CheckpointConfig.BatchesPerCheckpoint batches with shards
+// still remaining.
+type ScannerWorkflow struct {
+	name   string
+	shards []int
+	params ScannerWorkflowParams
+
+	reports           map[int]ScanReport
+	statuses          ShardStatusResult
+	aggregate         AggregateScanReportResult
+	batchesProcessed  int
+	checkpointReached bool
+	control           controlCoordinator
+}
+
+// NewScannerWorkflow validates params and constructs a ScannerWorkflow ready
+// to Start. name identifies the scanner (e.g. "executions") and is used to
+// resolve its GenericScannerConfig from dynamic config. If
+// params.ResumeFromCheckpoint is set, the returned ScannerWorkflow resumes
+// from it: shards it already recorded are skipped and its reports, statuses
+// and aggregate seed the queryable state of this run.
+func NewScannerWorkflow(ctx workflow.Context, name string, params ScannerWorkflowParams) (*ScannerWorkflow, error) {
+	if len(name) == 0 {
+		return nil, errors.New("workflow name is not provided")
+	}
+	shards, err := params.Shards.Flatten()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &ScannerWorkflow{
+		name:     name,
+		shards:   shards,
+		params:   params,
+		reports:  make(map[int]ScanReport),
+		statuses: make(ShardStatusResult),
+	}
+	if checkpoint := params.ResumeFromCheckpoint; checkpoint != nil {
+		for shardID, report := range checkpoint.ShardReports {
+			w.reports[shardID] = report
+		}
+		for shardID, status := range checkpoint.ShardStatuses {
+			w.statuses[shardID] = status
+		}
+		w.aggregate = checkpoint.Aggregate
+		w.shards = mergeUniqueSorted(w.shards, checkpoint.PendingShardIDs)
+	}
+	return w, nil
+}
+
+// Start runs the scanner workflow to completion, or until a configured
+// CheckpointConfig causes it to stop early and return a
+// ScannerContinueAsNewError for its caller to continue-as-new with. It
+// processes shards in rounds: each round partitions the currently pending
+// shards across Concurrency lanes, and shards injected via AddShardsSignal
+// or a changed Concurrency from RetuneSignal take effect at the start of
+// the next round. A ControlSignal with Action ControlActionAbort makes
+// Start return ErrWorkflowAborted.
+func (w *ScannerWorkflow) Start(ctx workflow.Context) error {
+	if err := w.registerQueries(ctx); err != nil {
+		return err
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, scannerConfigActivityOptions)
+	var resolved ResolvedScannerWorkflowConfig
+	if err := workflow.ExecuteActivity(ctx, ActivityScannerConfig, ScannerConfigActivityParams{Name: w.name}).Get(ctx, &resolved); err != nil {
+		return err
+	}
+	if !resolved.Enabled {
+		return nil
+	}
+	w.control.concurrency = resolved.Concurrency
+	w.control.batchSize = resolved.ActivityBatchSize
+
+	activityCtx := workflow.WithActivityOptions(ctx, w.activityOptions(resolved))
+	for {
+		if !w.control.awaitRunnable(ctx) {
+			return ErrWorkflowAborted
+		}
+		if injected := w.control.drainInjectedShards(); len(injected) > 0 {
+			w.shards = mergeUniqueSorted(w.shards, injected)
+		}
+		pending := remainingShardIDs(w.shards, w.statuses)
+		if len(pending) == 0 {
+			break
+		}
+		lanes := partitionShardIDs(pending, w.control.concurrency)
+
+		errCh := workflow.NewChannel(ctx)
+		for _, lane := range lanes {
+			lane := lane
+			workflow.Go(ctx, func(ctx workflow.Context) {
+				errCh.Send(ctx, w.runLane(activityCtx, lane, resolved.CheckpointConfig))
+			})
+		}
+
+		var firstErr error
+		for range lanes {
+			var laneErr error
+			errCh.Receive(ctx, &laneErr)
+			if laneErr != nil && firstErr == nil {
+				firstErr = laneErr
+			}
+		}
+		if firstErr != nil {
+			return firstErr
+		}
+		if w.checkpointReached {
+			break
+		}
+	}
+
+	if w.checkpointReached && len(remainingShardIDs(w.shards, w.statuses)) > 0 {
+		return &ScannerContinueAsNewError{NextParams: ScannerWorkflowParams{
+			Shards:               w.params.Shards,
+			ResumeFromCheckpoint: w.buildCheckpoint(),
+		}}
+	}
+	return nil
+}
+
+func (w *ScannerWorkflow) activityOptions(resolved ResolvedScannerWorkflowConfig) workflow.ActivityOptions {
+	return applyRetryPolicy(scanShardActivityOptions, resolved.ActivityRetryPolicy)
+}
+
+// runLane scans lane in batches sized by the latest RetuneSignal, checking
+// for a pause or abort via awaitRunnable before dispatching each batch.
+func (w *ScannerWorkflow) runLane(ctx workflow.Context, lane []int, checkpointConfig *CheckpointConfig) error {
+	for len(lane) > 0 {
+		if w.checkpointReached {
+			return nil
+		}
+		if !w.control.awaitRunnable(ctx) {
+			return ErrWorkflowAborted
+		}
+		batchSize := w.control.batchSize
+		if batchSize <= 0 || batchSize > len(lane) {
+			batchSize = len(lane)
+		}
+		batch := lane[:batchSize]
+		lane = lane[batchSize:]
+
+		var reports []ScanReport
+		if err := workflow.ExecuteActivity(ctx, ActivityScanShard, ScanShardActivityParams{Shards: batch}).Get(ctx, &reports); err != nil {
+			return err
+		}
+		w.recordReports(reports)
+		w.recordBatch(checkpointConfig)
+	}
+	return nil
+}
+
+// recordBatch counts a completed activity batch toward CheckpointConfig, if
+// one is configured, across every lane of this run. workflow.Go goroutines
+// only run one at a time between blocking calls, so this plain increment is
+// safe without additional synchronization.
+func (w *ScannerWorkflow) recordBatch(checkpointConfig *CheckpointConfig) {
+	if checkpointConfig == nil || checkpointConfig.BatchesPerCheckpoint <= 0 {
+		return
+	}
+	w.batchesProcessed++
+	if w.batchesProcessed >= checkpointConfig.BatchesPerCheckpoint {
+		w.checkpointReached = true
+	}
+}
+
+// buildCheckpoint returns a ScannerCheckpoint capturing this run's progress
+// so far, suitable for ScannerWorkflowParams.ResumeFromCheckpoint on a
+// continue-as-new.
+func (w *ScannerWorkflow) buildCheckpoint() *ScannerCheckpoint {
+	return &ScannerCheckpoint{
+		ShardReports:    w.reports,
+		ShardStatuses:   w.statuses,
+		Aggregate:       w.aggregate,
+		PendingShardIDs: remainingShardIDs(w.shards, w.statuses),
+	}
+}
+
+func (w *ScannerWorkflow) recordReports(reports []ScanReport) {
+	for _, report := range reports {
+		w.reports[report.ShardID] = report
+		if report.Result.ControlFlowFailure != nil {
+			w.statuses[report.ShardID] = ShardStatusControlFlowFailure
+			continue
+		}
+		w.statuses[report.ShardID] = ShardStatusSuccess
+		w.aggregate.EntitiesCount += report.Stats.EntitiesCount
+		w.aggregate.CorruptedCount += report.Stats.CorruptedCount
+		w.aggregate.CheckFailedCount += report.Stats.CheckFailedCount
+	}
+}
+
+func (w *ScannerWorkflow) registerQueries(ctx workflow.Context) error {
+	if err := w.control.registerQuery(ctx); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, ShardReportQuery, func(shardID int) (*ScanReport, error) {
+		report, ok := w.reports[shardID]
+		if !ok {
+			return nil, nil
+		}
+		return &report, nil
+	}); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, AggregateReportQuery, func() (AggregateScanReportResult, error) {
+		return w.aggregate, nil
+	}); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, ShardStatusQuery, func(req PaginatedShardQueryRequest) (*ShardStatusQueryResult, error) {
+		ids := make([]int, 0, len(w.statuses))
+		for id := range w.statuses {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		page, token := paginateShardIDs(ids, req)
+		result := make(ShardStatusResult, len(page))
+		for _, id := range page {
+			result[id] = w.statuses[id]
+		}
+		return &ShardStatusQueryResult{
+			Result:                    result,
+			ShardQueryPaginationToken: token,
+		}, nil
+	}); err != nil {
+		return err
+	}
+	return workflow.SetQueryHandler(ctx, CheckpointQuery, func() (*ScannerCheckpoint, error) {
+		return w.buildCheckpoint(), nil
+	})
+}
+
+func paginateShardIDs(ids []int, req PaginatedShardQueryRequest) ([]int, ShardQueryPaginationToken) {
+	if req.StartingShardID == nil && req.LimitShards == nil {
+		return ids, ShardQueryPaginationToken{IsDone: true}
+	}
+	start := 0
+	if req.StartingShardID != nil {
+		start = sort.SearchInts(ids, *req.StartingShardID)
+	}
+	end := len(ids)
+	if req.LimitShards != nil && start+*req.LimitShards < end {
+		end = start + *req.LimitShards
+	}
+	page := ids[start:end]
+	token := ShardQueryPaginationToken{IsDone: end >= len(ids)}
+	if !token.IsDone {
+		next := ids[end]
+		token.NextShardID = &next
+	}
+	return page, token
+}
@@ -0,0 +1,140 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package shardscanner
+
+import (
+	"errors"
+	"sort"
+)
+
+// ShardRange identifies a contiguous range of shards, [Min, Max).
+type ShardRange struct {
+	Min int
+	Max int
+}
+
+// Shards identifies the complete set of shards that a scanner or fixer
+// workflow should process. Exactly one of Range or List must be provided.
+type Shards struct {
+	Range *ShardRange
+	List  []int
+}
+
+// Flatten validates the Shards configuration and returns the sorted, de-duped
+// list of shard ids it describes.
+func (s Shards) Flatten() ([]int, error) {
+	if s.Range == nil && len(s.List) == 0 {
+		return nil, errors.New("must provide either List or Range")
+	}
+	if s.Range != nil && len(s.List) > 0 {
+		return nil, errors.New("must provide only one of List or Range")
+	}
+	if s.Range != nil {
+		if s.Range.Max <= s.Range.Min {
+			return nil, errors.New("range must satisfy Max > Min")
+		}
+		shards := make([]int, 0, s.Range.Max-s.Range.Min)
+		for i := s.Range.Min; i < s.Range.Max; i++ {
+			shards = append(shards, i)
+		}
+		return shards, nil
+	}
+	sorted := make([]int, len(s.List))
+	copy(sorted, s.List)
+	sort.Ints(sorted)
+	shards := make([]int, 0, len(sorted))
+	for _, shardID := range sorted {
+		if len(shards) == 0 || shards[len(shards)-1] != shardID {
+			shards = append(shards, shardID)
+		}
+	}
+	return shards, nil
+}
+
+// partitionShardIDs splits shards round-robin into concurrency lanes so that
+// each lane can be processed independently and in parallel.
+func partitionShardIDs(shards []int, concurrency int) [][]int {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	lanes := make([][]int, concurrency)
+	for i, shard := range shards {
+		lane := i % concurrency
+		lanes[lane] = append(lanes[lane], shard)
+	}
+	return lanes
+}
+
+// mergeUniqueSorted returns the sorted union of a and b with duplicates
+// removed, used to fold shards injected via AddShardsSignal back into a
+// resumed run's known shard set.
+func mergeUniqueSorted(a, b []int) []int {
+	seen := make(map[int]struct{}, len(a)+len(b))
+	merged := make([]int, 0, len(a)+len(b))
+	for _, shards := range [][]int{a, b} {
+		for _, shardID := range shards {
+			if _, ok := seen[shardID]; ok {
+				continue
+			}
+			seen[shardID] = struct{}{}
+			merged = append(merged, shardID)
+		}
+	}
+	sort.Ints(merged)
+	return merged
+}
+
+// partitionCorruptedKeys splits corrupted key entries round-robin into
+// concurrency lanes, mirroring partitionShardIDs but operating on
+// CorruptedKeysEntry values.
+func partitionCorruptedKeys(entries []CorruptedKeysEntry, concurrency int) [][]CorruptedKeysEntry {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	lanes := make([][]CorruptedKeysEntry, concurrency)
+	for i, entry := range entries {
+		lane := i % concurrency
+		lanes[lane] = append(lanes[lane], entry)
+	}
+	return lanes
+}
+
+// mergeUniqueCorruptedKeys returns the entries of a followed by any entry in
+// b whose ShardID does not already appear in a, used to fold shards injected
+// via AddShardsSignal into a fixer run's corrupted keys.
+func mergeUniqueCorruptedKeys(a, b []CorruptedKeysEntry) []CorruptedKeysEntry {
+	seen := make(map[int]struct{}, len(a))
+	merged := make([]CorruptedKeysEntry, 0, len(a)+len(b))
+	for _, entry := range a {
+		seen[entry.ShardID] = struct{}{}
+		merged = append(merged, entry)
+	}
+	for _, entry := range b {
+		if _, ok := seen[entry.ShardID]; ok {
+			continue
+		}
+		seen[entry.ShardID] = struct{}{}
+		merged = append(merged, entry)
+	}
+	return merged
+}
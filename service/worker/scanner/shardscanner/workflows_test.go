@@ -25,6 +25,7 @@ package shardscanner
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -50,7 +51,9 @@ func (s *workflowsSuite) SetupTest() {
 	s.env = s.WorkflowTestSuite.NewTestWorkflowEnvironment()
 	s.env.RegisterWorkflow(NewTestWorkflow)
 	s.env.RegisterWorkflow(NewTestFixerWorkflow)
+	s.env.RegisterWorkflow(NewTestCheckpointingWorkflow)
 	s.env.RegisterWorkflow(GetCorruptedKeys)
+	s.env.RegisterWorkflowWithOptions(testNotifyCorruptionChildWorkflow, workflow.RegisterOptions{Name: testNotifyChildWorkflowName})
 }
 
 func (s *workflowsSuite) TestScannerWorkflow_Failure_ScanShard() {
@@ -354,6 +357,325 @@ func (s *workflowsSuite) TestFixerWorkflow_Success() {
 	s.Equal(15, *status.ShardQueryPaginationToken.NextShardID)
 }
 
+// TestFixerWorkflow_NotificationSink_PerShard verifies that a NotificationSink
+// with Mode NotificationSinkPerShard signals TargetWorkflowID once for every
+// shard whose FixReport has a ControlFlowFailure or a non-zero FailedCount -
+// here, all 30 shards: 6 control-flow failures and 24 partially-failed fixes.
+func (s *workflowsSuite) TestFixerWorkflow_NotificationSink_PerShard() {
+	corruptedKeys := make([]CorruptedKeysEntry, 30)
+	for i := 0; i < 30; i++ {
+		corruptedKeys[i] = CorruptedKeysEntry{ShardID: i}
+	}
+	s.env.OnActivity(ActivityFixerCorruptedKeys, mock.Anything, mock.Anything).Return(&FixerCorruptedKeysActivityResult{
+		CorruptedKeys: corruptedKeys,
+		MinShard:      common.IntPtr(0),
+		MaxShard:      common.IntPtr(29),
+		ShardQueryPaginationToken: ShardQueryPaginationToken{
+			IsDone: true,
+		},
+	}, nil)
+
+	enabledFixInvariants := CustomScannerConfig{
+		invariant.CollectionHistory.String(): "true",
+	}
+	s.env.OnActivity(ActivityFixerConfig, mock.Anything, FixShardConfigParams{}).Return(&FixShardConfigResults{
+		EnabledInvariants: enabledFixInvariants,
+	}, nil)
+
+	fixerWorkflowConfigOverwrites := FixerWorkflowConfigOverwrites{
+		Concurrency:       common.IntPtr(3),
+		ActivityBatchSize: common.IntPtr(5),
+	}
+	resolvedFixerWorkflowConfig := ResolvedFixerWorkflowConfig{
+		Concurrency:       3,
+		ActivityBatchSize: 5,
+	}
+	batches := [][]int{
+		{0, 3, 6, 9, 12},
+		{15, 18, 21, 24, 27},
+		{1, 4, 7, 10, 13},
+		{16, 19, 22, 25, 28},
+		{2, 5, 8, 11, 14},
+		{17, 20, 23, 26, 29},
+	}
+	controlFlowFailureShards := map[int]bool{0: true, 1: true, 2: true, 15: true, 16: true, 17: true}
+
+	for _, batch := range batches {
+		var corruptedKeys []CorruptedKeysEntry
+		for _, shard := range batch {
+			corruptedKeys = append(corruptedKeys, CorruptedKeysEntry{ShardID: shard})
+		}
+		var reports []FixReport
+		for _, shard := range batch {
+			if controlFlowFailureShards[shard] {
+				reports = append(reports, FixReport{
+					ShardID: shard,
+					Result: FixResult{
+						ControlFlowFailure: &ControlFlowFailure{Info: "got control flow failure"},
+					},
+				})
+			} else {
+				reports = append(reports, FixReport{
+					ShardID: shard,
+					Stats:   FixStats{FixedCount: 2, FailedCount: 1, SkippedCount: 1},
+				})
+			}
+		}
+		s.env.OnActivity(ActivityFixShard, mock.Anything, FixShardActivityParams{
+			CorruptedKeysEntries:        corruptedKeys,
+			ResolvedFixerWorkflowConfig: resolvedFixerWorkflowConfig,
+			EnabledInvariants:           enabledFixInvariants,
+		}).Return(reports, nil)
+	}
+
+	var notifiedShards []int
+	s.env.OnSignalExternalWorkflow(mock.Anything, "test-target-workflow", mock.Anything, CorruptionNotificationSignalName, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			notification := args.Get(4).(CorruptionNotification)
+			notifiedShards = append(notifiedShards, notification.ShardID)
+		})
+
+	s.env.ExecuteWorkflow(NewTestFixerWorkflow, FixerWorkflowParams{
+		ScannerWorkflowWorkflowID:     "test_wid",
+		ScannerWorkflowRunID:          "test_rid",
+		FixerWorkflowConfigOverwrites: fixerWorkflowConfigOverwrites,
+		NotificationSink: &NotificationSink{
+			Mode:             NotificationSinkPerShard,
+			TargetWorkflowID: "test-target-workflow",
+		},
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	s.Len(notifiedShards, 30)
+	controlFlowFailures := 0
+	for _, shard := range notifiedShards {
+		if controlFlowFailureShards[shard] {
+			controlFlowFailures++
+		}
+	}
+	s.Equal(6, controlFlowFailures)
+	s.Equal(24, len(notifiedShards)-controlFlowFailures)
+}
+
+// TestFixerWorkflow_NotificationSink_PerBatch verifies that a
+// NotificationSink with Mode NotificationSinkPerBatch starts one child
+// workflow, of the registered type named by ChildWorkflowName, per activity
+// batch, carrying every shard's CorruptionNotification from that batch.
+func (s *workflowsSuite) TestFixerWorkflow_NotificationSink_PerBatch() {
+	corruptedKeys := make([]CorruptedKeysEntry, 30)
+	for i := 0; i < 30; i++ {
+		corruptedKeys[i] = CorruptedKeysEntry{ShardID: i}
+	}
+	s.env.OnActivity(ActivityFixerCorruptedKeys, mock.Anything, mock.Anything).Return(&FixerCorruptedKeysActivityResult{
+		CorruptedKeys: corruptedKeys,
+		MinShard:      common.IntPtr(0),
+		MaxShard:      common.IntPtr(29),
+		ShardQueryPaginationToken: ShardQueryPaginationToken{
+			IsDone: true,
+		},
+	}, nil)
+
+	enabledFixInvariants := CustomScannerConfig{
+		invariant.CollectionHistory.String(): "true",
+	}
+	s.env.OnActivity(ActivityFixerConfig, mock.Anything, FixShardConfigParams{}).Return(&FixShardConfigResults{
+		EnabledInvariants: enabledFixInvariants,
+	}, nil)
+
+	fixerWorkflowConfigOverwrites := FixerWorkflowConfigOverwrites{
+		Concurrency:       common.IntPtr(3),
+		ActivityBatchSize: common.IntPtr(5),
+	}
+	resolvedFixerWorkflowConfig := ResolvedFixerWorkflowConfig{
+		Concurrency:       3,
+		ActivityBatchSize: 5,
+	}
+	batches := [][]int{
+		{0, 3, 6, 9, 12},
+		{15, 18, 21, 24, 27},
+		{1, 4, 7, 10, 13},
+		{16, 19, 22, 25, 28},
+		{2, 5, 8, 11, 14},
+		{17, 20, 23, 26, 29},
+	}
+	controlFlowFailureShards := map[int]bool{0: true, 1: true, 2: true, 15: true, 16: true, 17: true}
+
+	for _, batch := range batches {
+		var corruptedKeys []CorruptedKeysEntry
+		for _, shard := range batch {
+			corruptedKeys = append(corruptedKeys, CorruptedKeysEntry{ShardID: shard})
+		}
+		var reports []FixReport
+		for _, shard := range batch {
+			if controlFlowFailureShards[shard] {
+				reports = append(reports, FixReport{
+					ShardID: shard,
+					Result: FixResult{
+						ControlFlowFailure: &ControlFlowFailure{Info: "got control flow failure"},
+					},
+				})
+			} else {
+				reports = append(reports, FixReport{
+					ShardID: shard,
+					Stats:   FixStats{FixedCount: 2, FailedCount: 1, SkippedCount: 1},
+				})
+			}
+		}
+		s.env.OnActivity(ActivityFixShard, mock.Anything, FixShardActivityParams{
+			CorruptedKeysEntries:        corruptedKeys,
+			ResolvedFixerWorkflowConfig: resolvedFixerWorkflowConfig,
+			EnabledInvariants:           enabledFixInvariants,
+		}).Return(reports, nil)
+	}
+
+	var notifiedShards []int
+	s.env.OnWorkflow(testNotifyChildWorkflowName, mock.Anything, mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			notifications := args.Get(1).([]CorruptionNotification)
+			for _, notification := range notifications {
+				notifiedShards = append(notifiedShards, notification.ShardID)
+			}
+		})
+
+	s.env.ExecuteWorkflow(NewTestFixerWorkflow, FixerWorkflowParams{
+		ScannerWorkflowWorkflowID:     "test_wid",
+		ScannerWorkflowRunID:          "test_rid",
+		FixerWorkflowConfigOverwrites: fixerWorkflowConfigOverwrites,
+		NotificationSink: &NotificationSink{
+			Mode:              NotificationSinkPerBatch,
+			ChildWorkflowName: testNotifyChildWorkflowName,
+		},
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	s.Len(notifiedShards, 30)
+}
+
+// TestFixerWorkflow_DryRun verifies that FixerWorkflowParams.DryRun runs the
+// full pipeline without mutating persistence: FixedCount stays 0,
+// PreviewedCount covers both enabled invariants for every non-control-flow-
+// failure shard (24 shards * 2 invariants = 48), and each such shard's report
+// carries a Preview distinguishing the two enabled invariants from the one
+// disabled invariant.
+func (s *workflowsSuite) TestFixerWorkflow_DryRun() {
+	corruptedKeys := make([]CorruptedKeysEntry, 30)
+	for i := 0; i < 30; i++ {
+		corruptedKeys[i] = CorruptedKeysEntry{ShardID: i}
+	}
+	s.env.OnActivity(ActivityFixerCorruptedKeys, mock.Anything, mock.Anything).Return(&FixerCorruptedKeysActivityResult{
+		CorruptedKeys: corruptedKeys,
+		MinShard:      common.IntPtr(0),
+		MaxShard:      common.IntPtr(29),
+		ShardQueryPaginationToken: ShardQueryPaginationToken{
+			IsDone: true,
+		},
+	}, nil)
+
+	enabledFixInvariants := CustomScannerConfig{
+		invariant.CollectionHistory.String():      "true",
+		invariant.CollectionMutableState.String(): "true",
+		invariant.CollectionStale.String():        "false",
+	}
+	s.env.OnActivity(ActivityFixerConfig, mock.Anything, FixShardConfigParams{}).Return(&FixShardConfigResults{
+		EnabledInvariants: enabledFixInvariants,
+	}, nil)
+
+	violationContext := &ViolationContext{
+		WatchName: "test-watch",
+		Project:   "test-project",
+		Caller:    "test-caller",
+	}
+
+	fixerWorkflowConfigOverwrites := FixerWorkflowConfigOverwrites{
+		Concurrency:       common.IntPtr(3),
+		ActivityBatchSize: common.IntPtr(5),
+	}
+	resolvedFixerWorkflowConfig := ResolvedFixerWorkflowConfig{
+		Concurrency:       3,
+		ActivityBatchSize: 5,
+	}
+	batches := [][]int{
+		{0, 3, 6, 9, 12},
+		{15, 18, 21, 24, 27},
+		{1, 4, 7, 10, 13},
+		{16, 19, 22, 25, 28},
+		{2, 5, 8, 11, 14},
+		{17, 20, 23, 26, 29},
+	}
+	controlFlowFailureShards := map[int]bool{0: true, 1: true, 2: true, 15: true, 16: true, 17: true}
+	preview := &PreviewResult{
+		ViolationContext: violationContext,
+		Invariants: []PreviewInvariantResult{
+			{InvariantName: invariant.CollectionHistory.String()},
+			{InvariantName: invariant.CollectionMutableState.String()},
+		},
+	}
+
+	for _, batch := range batches {
+		var corruptedKeys []CorruptedKeysEntry
+		for _, shard := range batch {
+			corruptedKeys = append(corruptedKeys, CorruptedKeysEntry{ShardID: shard})
+		}
+		var reports []FixReport
+		for _, shard := range batch {
+			if controlFlowFailureShards[shard] {
+				reports = append(reports, FixReport{
+					ShardID: shard,
+					Result: FixResult{
+						ControlFlowFailure: &ControlFlowFailure{Info: "got control flow failure"},
+					},
+				})
+			} else {
+				reports = append(reports, FixReport{
+					ShardID: shard,
+					Preview: preview,
+				})
+			}
+		}
+		s.env.OnActivity(ActivityFixShard, mock.Anything, FixShardActivityParams{
+			CorruptedKeysEntries:        corruptedKeys,
+			ResolvedFixerWorkflowConfig: resolvedFixerWorkflowConfig,
+			EnabledInvariants:           enabledFixInvariants,
+			DryRun:                      true,
+			ViolationContext:            violationContext,
+		}).Return(reports, nil)
+	}
+
+	s.env.ExecuteWorkflow(NewTestFixerWorkflow, FixerWorkflowParams{
+		ScannerWorkflowWorkflowID:     "test_wid",
+		ScannerWorkflowRunID:          "test_rid",
+		FixerWorkflowConfigOverwrites: fixerWorkflowConfigOverwrites,
+		DryRun:                        true,
+		ViolationContext:              violationContext,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	aggValue, err := s.env.QueryWorkflow(AggregateReportQuery)
+	s.NoError(err)
+	var agg AggregateFixReportResult
+	s.NoError(aggValue.Get(&agg))
+	s.Equal(int64(0), agg.FixedCount)
+	s.Equal(int64(48), agg.PreviewedCount)
+	s.Equal(PreviewInvariantBreakdown{FixCount: 24}, agg.PerInvariantPreview[invariant.CollectionHistory.String()])
+	s.Equal(PreviewInvariantBreakdown{FixCount: 24}, agg.PerInvariantPreview[invariant.CollectionMutableState.String()])
+	s.NotContains(agg.PerInvariantPreview, invariant.CollectionStale.String())
+
+	previewValue, err := s.env.QueryWorkflow(PreviewQuery)
+	s.NoError(err)
+	var previews map[int]*PreviewResult
+	s.NoError(previewValue.Get(&previews))
+	s.Len(previews, 24)
+	for shardID, shardPreview := range previews {
+		s.False(controlFlowFailureShards[shardID])
+		s.Equal(preview, shardPreview)
+	}
+}
+
 func (s *workflowsSuite) TestGetCorruptedKeys_Success() {
 	s.env.OnActivity(ActivityFixerCorruptedKeys, mock.Anything, FixerCorruptedKeysActivityParams{
 		ScannerWorkflowWorkflowID: "test_wid",
@@ -469,13 +791,272 @@ func (s *workflowsSuite) TestScannerWorkflow_Failure_CorruptedKeysActivity() {
 	s.Equal("got error getting corrupted keys", s.env.GetWorkflowError().Error())
 }
 
+// TestScannerWorkflow_Checkpoint_ResumeMatchesUninterrupted verifies that a
+// run which checkpoints and continues-as-new partway through, resumed via
+// ResumeFromCheckpoint, produces the same aggregate as a single run over the
+// same shards that never checkpoints.
+func (s *workflowsSuite) TestScannerWorkflow_Checkpoint_ResumeMatchesUninterrupted() {
+	shards := Shards{
+		Range: &ShardRange{
+			Min: 0,
+			Max: 10,
+		},
+	}
+	firstBatch := []int{0, 1, 2, 3, 4}
+	secondBatch := []int{5, 6, 7, 8, 9}
+	reportsFor := func(batch []int) []ScanReport {
+		reports := make([]ScanReport, 0, len(batch))
+		for _, shardID := range batch {
+			reports = append(reports, ScanReport{
+				ShardID: shardID,
+				Stats: ScanStats{
+					EntitiesCount:  10,
+					CorruptedCount: 1,
+				},
+			})
+		}
+		return reports
+	}
+
+	// Run 1: checkpoints after its first (and only allowed) batch, leaving
+	// the second batch unprocessed.
+	s.env.OnActivity(ActivityScannerConfig, mock.Anything, mock.Anything).Return(ResolvedScannerWorkflowConfig{
+		GenericScannerConfig: GenericScannerConfig{
+			Enabled:           true,
+			Concurrency:       1,
+			ActivityBatchSize: 5,
+			CheckpointConfig:  &CheckpointConfig{BatchesPerCheckpoint: 1},
+		},
+	}, nil)
+	s.env.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: firstBatch}).Return(reportsFor(firstBatch), nil)
+	s.env.ExecuteWorkflow(NewTestCheckpointingWorkflow, "test-workflow", ScannerWorkflowParams{Shards: shards})
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var checkpoint *ScannerCheckpoint
+	s.NoError(s.env.GetWorkflowResult(&checkpoint))
+	s.Len(checkpoint.ShardStatuses, len(firstBatch))
+
+	// Run 2: resumes from the checkpoint and finishes the remaining shards
+	// in a single, uninterrupted execution.
+	resumedEnv := s.WorkflowTestSuite.NewTestWorkflowEnvironment()
+	resumedEnv.RegisterWorkflow(NewTestWorkflow)
+	resumedEnv.OnActivity(ActivityScannerConfig, mock.Anything, mock.Anything).Return(ResolvedScannerWorkflowConfig{
+		GenericScannerConfig: GenericScannerConfig{
+			Enabled:           true,
+			Concurrency:       1,
+			ActivityBatchSize: 5,
+		},
+	}, nil)
+	resumedEnv.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: secondBatch}).Return(reportsFor(secondBatch), nil)
+	resumedEnv.ExecuteWorkflow(NewTestWorkflow, "test-workflow", ScannerWorkflowParams{
+		Shards:               shards,
+		ResumeFromCheckpoint: checkpoint,
+	})
+	s.True(resumedEnv.IsWorkflowCompleted())
+	s.NoError(resumedEnv.GetWorkflowError())
+
+	resumedAggValue, err := resumedEnv.QueryWorkflow(AggregateReportQuery)
+	s.NoError(err)
+	var resumedAgg AggregateScanReportResult
+	s.NoError(resumedAggValue.Get(&resumedAgg))
+
+	// Uninterrupted run: processes both batches in a single execution, with
+	// no CheckpointConfig set.
+	uninterruptedEnv := s.WorkflowTestSuite.NewTestWorkflowEnvironment()
+	uninterruptedEnv.RegisterWorkflow(NewTestWorkflow)
+	uninterruptedEnv.OnActivity(ActivityScannerConfig, mock.Anything, mock.Anything).Return(ResolvedScannerWorkflowConfig{
+		GenericScannerConfig: GenericScannerConfig{
+			Enabled:           true,
+			Concurrency:       1,
+			ActivityBatchSize: 5,
+		},
+	}, nil)
+	uninterruptedEnv.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: firstBatch}).Return(reportsFor(firstBatch), nil)
+	uninterruptedEnv.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: secondBatch}).Return(reportsFor(secondBatch), nil)
+	uninterruptedEnv.ExecuteWorkflow(NewTestWorkflow, "test-workflow", ScannerWorkflowParams{Shards: shards})
+	s.True(uninterruptedEnv.IsWorkflowCompleted())
+	s.NoError(uninterruptedEnv.GetWorkflowError())
+
+	uninterruptedAggValue, err := uninterruptedEnv.QueryWorkflow(AggregateReportQuery)
+	s.NoError(err)
+	var uninterruptedAgg AggregateScanReportResult
+	s.NoError(uninterruptedAggValue.Get(&uninterruptedAgg))
+
+	s.Equal(uninterruptedAgg, resumedAgg)
+}
+
+// TestScannerWorkflow_PauseResume verifies that a ControlSignal with Action
+// ControlActionPause stops a run from dispatching its next batch until a
+// ControlActionResume arrives, and that the full shard set still completes
+// with the correct aggregate once resumed.
+func (s *workflowsSuite) TestScannerWorkflow_PauseResume() {
+	s.env.OnActivity(ActivityScannerConfig, mock.Anything, mock.Anything).Return(ResolvedScannerWorkflowConfig{
+		GenericScannerConfig: GenericScannerConfig{
+			Enabled:           true,
+			Concurrency:       1,
+			ActivityBatchSize: 5,
+		},
+	}, nil)
+	shards := Shards{
+		Range: &ShardRange{
+			Min: 0,
+			Max: 15,
+		},
+	}
+	batches := [][]int{
+		{0, 1, 2, 3, 4},
+		{5, 6, 7, 8, 9},
+		{10, 11, 12, 13, 14},
+	}
+	reportsFor := func(batch []int) []ScanReport {
+		reports := make([]ScanReport, 0, len(batch))
+		for _, shardID := range batch {
+			reports = append(reports, ScanReport{
+				ShardID: shardID,
+				Stats:   ScanStats{EntitiesCount: 10},
+			})
+		}
+		return reports
+	}
+
+	s.env.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: batches[0]}).Return(reportsFor(batches[0]), nil).Once()
+	s.env.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: batches[1]}).Return(reportsFor(batches[1]), nil).Once().Run(func(args mock.Arguments) {
+		s.env.SignalWorkflow(ControlSignalName, ControlSignal{Action: ControlActionPause})
+	})
+	s.env.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: batches[2]}).Return(reportsFor(batches[2]), nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		stateValue, err := s.env.QueryWorkflow(ControlStateQuery)
+		s.NoError(err)
+		var state ControlStateQueryResult
+		s.NoError(stateValue.Get(&state))
+		s.True(state.Paused)
+		s.False(s.env.IsWorkflowCompleted())
+		s.env.SignalWorkflow(ControlSignalName, ControlSignal{Action: ControlActionResume})
+	}, time.Minute)
+
+	s.env.ExecuteWorkflow(NewTestWorkflow, "test-workflow", ScannerWorkflowParams{Shards: shards})
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	aggValue, err := s.env.QueryWorkflow(AggregateReportQuery)
+	s.NoError(err)
+	var agg AggregateScanReportResult
+	s.NoError(aggValue.Get(&agg))
+	s.Equal(AggregateScanReportResult{EntitiesCount: 150}, agg)
+}
+
+// TestScannerWorkflow_Abort verifies that a ControlSignal with Action
+// ControlActionAbort stops the run before it dispatches any further
+// activities and surfaces ErrWorkflowAborted.
+func (s *workflowsSuite) TestScannerWorkflow_Abort() {
+	// Signaling from within the ActivityScannerConfig mock's Run callback -
+	// rather than via RegisterDelayedCallback - guarantees the abort signal
+	// is already queued by the time Start reaches its first round, before
+	// ActivityScanShard would otherwise be dispatched. A mocked activity
+	// completes without advancing the test environment's simulated clock, so
+	// a callback timer (even one registered for time 0) is not guaranteed to
+	// run before that first round.
+	s.env.OnActivity(ActivityScannerConfig, mock.Anything, mock.Anything).Return(ResolvedScannerWorkflowConfig{
+		GenericScannerConfig: GenericScannerConfig{
+			Enabled:           true,
+			Concurrency:       1,
+			ActivityBatchSize: 5,
+		},
+	}, nil).Run(func(args mock.Arguments) {
+		s.env.SignalWorkflow(ControlSignalName, ControlSignal{Action: ControlActionAbort})
+	})
+	shards := Shards{
+		Range: &ShardRange{
+			Min: 0,
+			Max: 5,
+		},
+	}
+
+	s.env.ExecuteWorkflow(NewTestWorkflow, "test-workflow", ScannerWorkflowParams{Shards: shards})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Equal(ErrWorkflowAborted.Error(), s.env.GetWorkflowError().Error())
+}
+
+// TestScannerWorkflow_AddShardsSignal verifies that shards injected via
+// AddShardsSignal are picked up in a later round of the same run, without
+// any CheckpointConfig or continue-as-new involved.
+func (s *workflowsSuite) TestScannerWorkflow_AddShardsSignal() {
+	s.env.OnActivity(ActivityScannerConfig, mock.Anything, mock.Anything).Return(ResolvedScannerWorkflowConfig{
+		GenericScannerConfig: GenericScannerConfig{
+			Enabled:           true,
+			Concurrency:       1,
+			ActivityBatchSize: 5,
+		},
+	}, nil)
+	shards := Shards{
+		List: []int{0, 1, 2, 3, 4},
+	}
+	firstBatch := []int{0, 1, 2, 3, 4}
+	injectedBatch := []int{10}
+	reportsFor := func(batch []int) []ScanReport {
+		reports := make([]ScanReport, 0, len(batch))
+		for _, shardID := range batch {
+			reports = append(reports, ScanReport{
+				ShardID: shardID,
+				Stats:   ScanStats{EntitiesCount: 10},
+			})
+		}
+		return reports
+	}
+	// Signaling from within the firstBatch activity's Run callback - rather
+	// than via RegisterDelayedCallback - guarantees the signal is queued
+	// before the round loop decides whether any shards remain. A mocked
+	// activity completes without advancing the test environment's simulated
+	// clock, so with only one round of known shards, a callback timer (even
+	// one registered for 1ms) would never fire before the run - seeing no
+	// pending shards left - exits.
+	s.env.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: firstBatch}).Return(reportsFor(firstBatch), nil).Once().Run(func(args mock.Arguments) {
+		s.env.SignalWorkflow(AddShardsSignalName, AddShardsSignal{Shards: injectedBatch})
+	})
+	s.env.OnActivity(ActivityScanShard, mock.Anything, ScanShardActivityParams{Shards: injectedBatch}).Return(reportsFor(injectedBatch), nil).Once()
+
+	s.env.ExecuteWorkflow(NewTestWorkflow, "test-workflow", ScannerWorkflowParams{Shards: shards})
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	aggValue, err := s.env.QueryWorkflow(AggregateReportQuery)
+	s.NoError(err)
+	var agg AggregateScanReportResult
+	s.NoError(aggValue.Get(&agg))
+	s.Equal(AggregateScanReportResult{EntitiesCount: 60}, agg)
+}
+
 func NewTestWorkflow(ctx workflow.Context, name string, params ScannerWorkflowParams) error {
 	wf, err := NewScannerWorkflow(ctx, name, params)
 	if err != nil {
 		return err
 	}
 
-	return wf.Start(ctx)
+	err = wf.Start(ctx)
+	var continueAsNew *ScannerContinueAsNewError
+	if errors.As(err, &continueAsNew) {
+		return workflow.NewContinueAsNewError(ctx, NewTestWorkflow, name, continueAsNew.NextParams)
+	}
+	return err
+}
+
+// NewTestCheckpointingWorkflow runs a ScannerWorkflow and, instead of
+// actually continuing-as-new, returns the checkpoint it would have resumed
+// from. This lets tests drive a resumed run explicitly without depending on
+// the test environment's continue-as-new replay behavior.
+func NewTestCheckpointingWorkflow(ctx workflow.Context, name string, params ScannerWorkflowParams) (*ScannerCheckpoint, error) {
+	wf, err := NewScannerWorkflow(ctx, name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wf.Start(ctx)
+	var continueAsNew *ScannerContinueAsNewError
+	if errors.As(err, &continueAsNew) {
+		return continueAsNew.NextParams.ResumeFromCheckpoint, nil
+	}
+	return nil, err
 }
 
 func NewTestFixerWorkflow(ctx workflow.Context, params FixerWorkflowParams) error {
@@ -484,6 +1065,23 @@ func NewTestFixerWorkflow(ctx workflow.Context, params FixerWorkflowParams) erro
 		return err
 	}
 
-	return wf.Start(ctx)
+	err = wf.Start(ctx)
+	var continueAsNew *FixerContinueAsNewError
+	if errors.As(err, &continueAsNew) {
+		return workflow.NewContinueAsNewError(ctx, NewTestFixerWorkflow, continueAsNew.NextParams)
+	}
+	return err
+
+}
+
+// testNotifyChildWorkflowName is the registered name used by tests for
+// NotificationSinkPerBatch, standing in for a real downstream consumer
+// workflow (alerting, ticketing, replication repair).
+const testNotifyChildWorkflowName = "test-notify-corruption-child-workflow"
 
+// testNotifyCorruptionChildWorkflow is a stand-in downstream consumer
+// workflow for NotificationSinkPerBatch tests; it does nothing on its own
+// and is driven entirely through s.env.OnWorkflow mocking.
+func testNotifyCorruptionChildWorkflow(ctx workflow.Context, notifications []CorruptionNotification) error {
+	return nil
 }